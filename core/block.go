@@ -5,7 +5,6 @@ import (
 	"crypto/sha256"
 	"encoding/gob"
 	"log"
-	"time"
 )
 
 // 区块结构的声明
@@ -17,11 +16,11 @@ type Block struct {
 	Nonce         int    //用于验证工作量证明的随机数
 }
 
-// 定义一个新区快并返回
-func NewBlock(transactions []*Transaction, prevBlockHash []byte) *Block {
+// 定义一个新区快并返回，timestamp 为区块的时间戳
+func NewBlock(transactions []*Transaction, prevBlockHash []byte, timestamp int64) *Block {
 	//声明一个区块（Block结构体）
 	block := &Block{
-		Timestamp:     time.Now().Unix(),
+		Timestamp:     timestamp,
 		Transactions:  transactions,
 		PrevBlockHash: prevBlockHash,
 		Hash:          []byte{},
@@ -37,9 +36,20 @@ func NewBlock(transactions []*Transaction, prevBlockHash []byte) *Block {
 	return block
 }
 
-// 创世纪区块的创建
-func NewGenesisBlock(coinbase *Transaction) *Block {
-	return NewBlock([]*Transaction{coinbase}, []byte{})
+// 创世纪区块的创建：内容全部来自当前网络参数，随机数已知，无需再挖矿
+func NewGenesisBlock() *Block {
+	coinbase := NewCoinbaseTX(activeNet.GenesisAddress, activeNet.GenesisCoinbaseData, 0)
+	block := &Block{
+		Timestamp:     activeNet.GenesisTimestamp,
+		Transactions:  []*Transaction{coinbase},
+		PrevBlockHash: []byte{},
+		Hash:          []byte{},
+		Nonce:         activeNet.GenesisNonce}
+
+	hash := sha256.Sum256(NewProofOfWork(block).prepareData(block.Nonce))
+	block.Hash = hash[:]
+
+	return block
 }
 
 // 计算区块里所有交易的哈希
@@ -1,18 +1,24 @@
 package core
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	//bolt是一种开源的key-value数据储存库
 	"github.com/boltdb/bolt"
 )
 
-const dbFile = "blockchain.db"		//区块链数据存放文件
+const dbFile = "blockchain.db"		//区块链数据存放文件，位于当前网络的数据目录下
 const blocksBucket = "blocks"		//区块数据存放‘桶’
-const genesisCoinbaseData = "Blank Data"
+
+const medianTimeBlocks = 11			//计算中位时间（median-time-past）所用的区块数
+const maxFutureBlockTime = 2 * 60 * 60	//区块时间戳最多可以超前本地时间多少秒
 
 //  bolt “数据库”结构声明
 type Blockchain struct {
@@ -26,8 +32,13 @@ type BlockchainIterator struct {
 	Db *bolt.DB
 }
 
-//申请添加一个新的区块
-func (bc *Blockchain) AddBlock(transactions []*Transaction) {
+//申请添加一个新的区块，返回挖出的区块
+func (bc *Blockchain) AddBlock(transactions []*Transaction) *Block {
+	return bc.addBlock(transactions, bc.newTxOutputSet())
+}
+
+// addBlock 添加新区块，txSet 为当前链的记录（包括最新高度），添加成功后一并更新
+func (bc *Blockchain) addBlock(transactions []*Transaction, txSet *txOutputSet) *Block {
 	var lastHash []byte
 
 	err := bc.Db.View(func(tx *bolt.Tx) error {
@@ -40,7 +51,21 @@ func (bc *Blockchain) AddBlock(transactions []*Transaction) {
 	if err != nil {
 		log.Panic(err)
 	}
-	newBlock := NewBlock(transactions, lastHash)
+	//时间戳取当前时间，但必须大于最近区块的中位时间
+	mtp := bc.medianTimePast(lastHash)
+	timestamp := time.Now().Unix()
+	if timestamp <= mtp {
+		timestamp = mtp + 1
+	}
+	err = bc.checkBlockTransactions(transactions, txSet.height+1, txSet)
+	if err != nil {
+		log.Panic(err)
+	}
+	newBlock := NewBlock(transactions, lastHash, timestamp)
+	err = checkBlockTimestamp(newBlock, mtp)
+	if err != nil {
+		log.Panic(err)
+	}
 	//生成一个新区块并序列化以便存入‘桶’
 	err = bc.Db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
@@ -58,6 +83,175 @@ func (bc *Blockchain) AddBlock(transactions []*Transaction) {
 		bc.tip = newBlock.Hash
 		return nil
 	})
+	if err != nil {
+		log.Panic(err)
+	}
+	txSet.add(transactions)
+
+	return newBlock
+}
+
+// medianTimePast 返回以 hash 为最新区块时，最近 medianTimeBlocks 个区块时间戳的中位数
+// hash 为空（创世区块之前）时返回 0
+func (bc *Blockchain) medianTimePast(hash []byte) int64 {
+	var timestamps []int64
+	if len(hash) == 0 {
+		return 0
+	}
+	bci := &BlockchainIterator{hash, bc.Db}
+
+	for len(timestamps) < medianTimeBlocks {
+		block := bci.Next()
+		timestamps = append(timestamps, block.Timestamp)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return timestamps[len(timestamps)/2]
+}
+
+// CheckBlockTimestamp 校验区块的时间戳：必须大于其父区块的中位时间，
+// 且不能超前本地时间两个小时以上
+func (bc *Blockchain) CheckBlockTimestamp(block *Block) error {
+	return checkBlockTimestamp(block, bc.medianTimePast(block.PrevBlockHash))
+}
+
+// checkBlockTimestamp 用已经算好的父区块中位时间 mtp 校验区块的时间戳
+func checkBlockTimestamp(block *Block, mtp int64) error {
+	if block.Timestamp <= mtp {
+		return fmt.Errorf("ERROR: block timestamp %d is not after the median time past %d", block.Timestamp, mtp)
+	}
+
+	maxTime := time.Now().Unix() + maxFutureBlockTime
+	if block.Timestamp > maxTime {
+		return fmt.Errorf("ERROR: block timestamp %d is too far in the future (max %d)", block.Timestamp, maxTime)
+	}
+
+	return nil
+}
+
+// txOutputSet 记录链上每笔交易的输出数量及其中已被花费的数量，用于发现重复的交易 ID；
+// 同时记录最新区块的高度，新区块的高度由此得出，而不由调用方给出
+type txOutputSet struct {
+	outputs map[string]int
+	spent   map[string]int
+	height  int
+}
+
+// newTxOutputSet 遍历一次区块链，建立 txOutputSet
+func (bc *Blockchain) newTxOutputSet() *txOutputSet {
+	txSet := &txOutputSet{make(map[string]int), make(map[string]int), -1}
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		txSet.add(block.Transactions)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return txSet
+}
+
+// add 记录一个新上链区块中的交易，最新高度随之加一
+func (s *txOutputSet) add(transactions []*Transaction) {
+	s.height++
+
+	for _, tx := range transactions {
+		s.outputs[hex.EncodeToString(tx.ID)] = len(tx.Vout)
+
+		if tx.IsCoinbase() == false {
+			for _, in := range tx.Vin {
+				s.spent[hex.EncodeToString(in.Txid)]++
+			}
+		}
+	}
+}
+
+// hasUnspent 判断链上是否有 ID 为 txID 且仍有未花费输出的交易
+func (s *txOutputSet) hasUnspent(txID []byte) bool {
+	id := hex.EncodeToString(txID)
+	return s.outputs[id] > s.spent[id]
+}
+
+// checkBlockTransactions 校验将要放入高度为 height 的区块中的交易：
+// coinbase 只能是第一笔交易且必须承诺区块高度，
+// 交易 ID 不能与链上仍有未花费输出的交易重复，
+// 其他交易须通过 VerifyTransaction（包括 coinbase 成熟度），且同一区块内不能重复花费同一输出
+func (bc *Blockchain) checkBlockTransactions(transactions []*Transaction, height int, txSet *txOutputSet) error {
+	used := make(map[string]bool)
+
+	for i, tx := range transactions {
+		if tx.IsCoinbase() {
+			if i != 0 {
+				return fmt.Errorf("ERROR: coinbase must be the first transaction in a block")
+			}
+			committed, err := tx.CoinbaseHeight()
+			if err != nil {
+				return fmt.Errorf("ERROR: %v", err)
+			}
+			if committed != height {
+				return fmt.Errorf("ERROR: coinbase commits to height %d, block height is %d", committed, height)
+			}
+		}
+
+		if txSet.hasUnspent(tx.ID) {
+			return fmt.Errorf("ERROR: transaction %x duplicates a transaction with unspent outputs", tx.ID)
+		}
+
+		if tx.IsCoinbase() == false {
+			err := bc.VerifyTransaction(tx)
+			if err != nil {
+				return fmt.Errorf("ERROR: transaction %x is invalid: %v", tx.ID, err)
+			}
+			for _, in := range tx.Vin {
+				outpoint := fmt.Sprintf("%x:%d", in.Txid, in.Vout)
+				if used[outpoint] {
+					return fmt.Errorf("ERROR: output %s is spent twice in the block", outpoint)
+				}
+				used[outpoint] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetBestHeight 返回最新区块的高度，创世区块高度为 0
+func (bc *Blockchain) GetBestHeight() int {
+	height := 0
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+		height++
+	}
+
+	return height
+}
+
+// GenerateBlocks 立即挖出 n 个只包含 coinbase 交易的区块，奖励发给 address
+// 主要用于 regtest 网络，让测试可以精确地构造链的状态
+func (bc *Blockchain) GenerateBlocks(n int, address string) []*Block {
+	var blocks []*Block
+	//只遍历一次区块链，之后随新区块增量更新
+	txSet := bc.newTxOutputSet()
+
+	for i := 0; i < n; i++ {
+		cbtx := NewCoinbaseTX(address, "", txSet.height+1)
+		blocks = append(blocks, bc.addBlock([]*Transaction{cbtx}, txSet))
+	}
+
+	return blocks
 }
 
 //迭代器，传递bolt的“数据库”
@@ -92,49 +286,68 @@ func (i *BlockchainIterator) Next() *Block {
 func NewBlockchain(address string) *Blockchain {
 	//判断是否有区块链存在
 	if dbExists() == false {
-		fmt.Println("No existing blockchain found. Create one first.")
+		fmt.Printf("No existing blockchain found at %s. Create one first.\n", dbPath())
 		os.Exit(1)
 	}
 	var tip []byte
 	//打开“区块链数据存放文件”，若失败则报错
-	db,err := bolt.Open(dbFile,0600,nil)
+	db,err := bolt.Open(dbPath(),0600,nil)
 	if err != nil{
 		log.Panic(err)
 	}
+	var genesisFound bool
 	err = db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		tip = b.Get([]byte("l"))
 
+		//库中只保存一条链，创世区块在库中即说明这条链从它开始
+		genesisHash, err := hex.DecodeString(activeNet.GenesisHash)
+		if err != nil {
+			return err
+		}
+		genesisFound = b.Get(genesisHash) != nil
+
 		return nil
 	})
 
 	if err != nil {
 		log.Panic(err)
 	}
+	if genesisFound == false {
+		db.Close()
+		fmt.Printf("The blockchain at %s does not start with the %s genesis block %s. Remove it and create it again.\n", dbPath(), activeNet.Name, activeNet.GenesisHash)
+		os.Exit(1)
+	}
 
 	bc := Blockchain{tip, db}
 
 	return &bc
 }
 
-// CreateBlockchain 创建一个新的区块链数据库
-// address 用来接收挖出创世块的奖励
-func CreateBlockchain(address string) *Blockchain {
+// CreateBlockchain 创建一个新的区块链数据库，写入当前网络的创世区块
+func CreateBlockchain() *Blockchain {
 	if dbExists() {
-		fmt.Println("Blockchain already exists.")
+		fmt.Printf("Blockchain already exists at %s.\n", dbPath())
 		os.Exit(1)
 	}
 
+	genesis := NewGenesisBlock()
+	if hex.EncodeToString(genesis.Hash) != activeNet.GenesisHash || !NewProofOfWork(genesis).Validate() {
+		log.Panicf("ERROR: genesis block %x does not match the %s parameters", genesis.Hash, activeNet.Name)
+	}
+
 	var tip []byte
-	db, err := bolt.Open(dbFile, 0600, nil)
+	//每个网络使用各自的数据目录
+	err := os.MkdirAll(filepath.Dir(dbPath()), 0700)
+	if err != nil {
+		log.Panic(err)
+	}
+	db, err := bolt.Open(dbPath(), 0600, nil)
 	if err != nil {
 		log.Panic(err)
 	}
 	//向“区块链数据存放文件”写入数据
 	err = db.Update(func(tx *bolt.Tx) error {
-		cbtx := NewCoinbaseTX(address,genesisCoinbaseData)
-		genesis := NewGenesisBlock(cbtx)
-
 		//申请一个‘桶’
 		b, err := tx.CreateBucket([]byte(blocksBucket))
 		if err != nil {
@@ -166,14 +379,16 @@ func CreateBlockchain(address string) *Blockchain {
 	return &bc
 }
 
-// FindUnspentTransactions 找到未花费输出的交易
-func (bc *Blockchain) FindUnspentTransactions(address string) []Transaction {
-	var unspentTXs []Transaction
+// FindUnspentOutputs 找到 address 可以解锁的所有未花费输出，并记录其位置
+func (bc *Blockchain) FindUnspentOutputs(address string) []UTXO {
+	var UTXOs []UTXO
 	spentTXOs := make(map[string][]int)
 	bci := bc.Iterator()
+	confirmations := 0
 
 	for {
 		block := bci.Next()
+		confirmations++
 
 		for _, tx := range block.Transactions {
 			txID := hex.EncodeToString(tx.ID)
@@ -181,17 +396,14 @@ func (bc *Blockchain) FindUnspentTransactions(address string) []Transaction {
 		Outputs:
 			for outIdx, out := range tx.Vout {
 				// 如果交易输出被花费了
-				if spentTXOs[txID] != nil {
-					for _, spentOut := range spentTXOs[txID] {
-						if spentOut == outIdx {
-							continue Outputs
-						}
+				for _, spentOut := range spentTXOs[txID] {
+					if spentOut == outIdx {
+						continue Outputs
 					}
 				}
 
-				// 如果该交易输出可以被解锁，即可被花费
 				if out.CanBeUnlockedWith(address) {
-					unspentTXs = append(unspentTXs, *tx)
+					UTXOs = append(UTXOs, UTXO{txID, outIdx, out, tx.IsCoinbase(), confirmations})
 				}
 			}
 
@@ -210,45 +422,154 @@ func (bc *Blockchain) FindUnspentTransactions(address string) []Transaction {
 		}
 	}
 
-	return unspentTXs
+	return UTXOs
 }
 
-func (bc *Blockchain) FindUTXO(address string) []TXOutput {
-	var UTXOs []TXOutput
-	unspentTransactions := bc.FindUnspentTransactions(address)
+// findUnspentOutputsOf 找到多个地址的所有未花费输出，重复的地址只计一次
+func (bc *Blockchain) findUnspentOutputsOf(addresses []string) []UTXO {
+	var UTXOs []UTXO
+	seen := make(map[string]bool)
 
-	for _, tx := range unspentTransactions {
-		for _, out := range tx.Vout {
-			if out.CanBeUnlockedWith(address) {
-				UTXOs = append(UTXOs, out)
-			}
+	for _, address := range addresses {
+		if seen[address] {
+			continue
 		}
+		seen[address] = true
+		UTXOs = append(UTXOs, bc.FindUnspentOutputs(address)...)
 	}
 
 	return UTXOs
 }
 
-// FindSpendableOutputs 从 address 中找到至少 amount 的 UTXO
-func (bc *Blockchain) FindSpendableOutputs(address string, amount int) (int, map[string][]int) {
-	unspentOutputs := make(map[string][]int)
-	unspentTXs := bc.FindUnspentTransactions(address)
-	accumulated := 0
+// FindSpendableOutputs 按 selector 的策略从 addresses 的 UTXO 中找到至少 amount 的输出
+// 尚未成熟的 coinbase 输出不会被选中
+func (bc *Blockchain) FindSpendableOutputs(addresses []string, amount int, selector CoinSelector) (int, []UTXO) {
+	var candidates []UTXO
 
-Work:
-	for _, tx := range unspentTXs {
-		txID := hex.EncodeToString(tx.ID)
+	for _, u := range bc.findUnspentOutputsOf(addresses) {
+		if u.IsMature() {
+			candidates = append(candidates, u)
+		}
+	}
+	selected := selector.SelectCoins(candidates, amount)
 
-		for outIdx, out := range tx.Vout {
-			if out.CanBeUnlockedWith(address) && accumulated < amount {
-				accumulated += out.Value
-				unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
+	return sumUTXOs(selected), selected
+}
 
-				if accumulated >= amount {
-					break Work
-				}
+// FindTransaction 按 ID 在区块链中查找交易
+func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
+	tx, _, err := bc.findTransactionWithConfirmations(ID)
+	return tx, err
+}
+
+// findTransactionWithConfirmations 查找交易，同时返回它所在区块的确认数
+func (bc *Blockchain) findTransactionWithConfirmations(ID []byte) (Transaction, int, error) {
+	bci := bc.Iterator()
+	confirmations := 0
+
+	for {
+		block := bci.Next()
+		confirmations++
+
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, ID) {
+				return *tx, confirmations, nil
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return Transaction{}, 0, fmt.Errorf("transaction %x is not found", ID)
+}
+
+// findSpentOutputs 找到区块链中所有已被花费的输出，key 为交易 ID（十六进制）
+func (bc *Blockchain) findSpentOutputs() map[string][]int {
+	spentTXOs := make(map[string][]int)
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			if tx.IsCoinbase() {
+				continue
+			}
+			for _, in := range tx.Vin {
+				inTxID := hex.EncodeToString(in.Txid)
+				spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Vout)
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return spentTXOs
+}
+
+// VerifyTransaction 校验一笔待上链的交易：
+// ID 与内容一致，所有输入引用的输出都存在、未被花费且已由其所有者解锁，
+// coinbase 输出已经成熟，并且输入总额不小于输出总额
+func (bc *Blockchain) VerifyTransaction(tx *Transaction) error {
+	if tx.IsCoinbase() {
+		return fmt.Errorf("coinbase transactions cannot be sent")
+	}
+	if !bytes.Equal(tx.ID, tx.Hash()) {
+		return fmt.Errorf("transaction ID does not match its contents")
+	}
+	if len(tx.Vin) == 0 || len(tx.Vout) == 0 {
+		return fmt.Errorf("transaction must have at least one input and one output")
+	}
+
+	outputTotal := 0
+	for n, out := range tx.Vout {
+		if out.Value <= 0 || out.ScriptPubKey == "" {
+			return fmt.Errorf("output %d: amount must be positive and address must be set", n)
+		}
+		outputTotal += out.Value
+	}
+
+	spentTXOs := bc.findSpentOutputs()
+	used := make(map[string]bool)
+	inputTotal := 0
+
+	for i, in := range tx.Vin {
+		outpoint := fmt.Sprintf("%x:%d", in.Txid, in.Vout)
+		if used[outpoint] {
+			return fmt.Errorf("input %d: %s is spent twice", i, outpoint)
+		}
+		used[outpoint] = true
+
+		prevTx, confirmations, err := bc.findTransactionWithConfirmations(in.Txid)
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+		if prevTx.IsCoinbase() && confirmations < activeNet.CoinbaseMaturity {
+			return fmt.Errorf("input %d: coinbase output %s is immature (%d of %d confirmations)", i, outpoint, confirmations, activeNet.CoinbaseMaturity)
+		}
+		if in.Vout < 0 || in.Vout >= len(prevTx.Vout) {
+			return fmt.Errorf("input %d: output %s does not exist", i, outpoint)
+		}
+		for _, spentOut := range spentTXOs[hex.EncodeToString(in.Txid)] {
+			if spentOut == in.Vout {
+				return fmt.Errorf("input %d: output %s is already spent", i, outpoint)
 			}
 		}
+
+		prevOut := prevTx.Vout[in.Vout]
+		if !prevOut.CanBeUnlockedWith(in.ScriptSig) {
+			return fmt.Errorf("input %d: output %s is not signed by its owner", i, outpoint)
+		}
+		inputTotal += prevOut.Value
 	}
 
-	return accumulated, unspentOutputs
-}
\ No newline at end of file
+	if inputTotal < outputTotal {
+		return fmt.Errorf("outputs (%d) exceed inputs (%d)", outputTotal, inputTotal)
+	}
+
+	return nil
+}
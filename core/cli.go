@@ -1,19 +1,36 @@
 package core
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 type CLI struct {}
 
-func (cli *CLI) createBlockchain(address string) {
-	bc := CreateBlockchain(address)
+func (cli *CLI) createBlockchain() {
+	bc := CreateBlockchain()
 	bc.Db.Close()
-	fmt.Println("Done!")
+	fmt.Printf("Done! Blockchain created at %s\n", dbPath())
+}
+
+func (cli *CLI) generate(n int, address string) {
+	bc := NewBlockchain(address)
+	defer bc.Db.Close()
+
+	blocks := bc.GenerateBlocks(n, address)
+	for _, block := range blocks {
+		fmt.Printf("%x\n", block.Hash)
+	}
+	fmt.Printf("Generated %d block(s), best height %d\n", len(blocks), bc.GetBestHeight())
 }
 
 func (cli *CLI) getBalance(address string) {
@@ -21,22 +38,45 @@ func (cli *CLI) getBalance(address string) {
 	defer bc.Db.Close()
 
 	balance := 0
-	UTXOs := bc.FindUTXO(address)
+	immature := 0
+	UTXOs := bc.FindUnspentOutputs(address)
 
-	for _, out := range UTXOs {
-		balance += out.Value
+	//尚未成熟的 coinbase 奖励不能花费，单独列出
+	for _, u := range UTXOs {
+		if u.IsMature() {
+			balance += u.Output.Value
+		} else {
+			immature += u.Output.Value
+		}
 	}
 
 	fmt.Printf("Balance of '%s': %d\n", address, balance)
+	if immature > 0 {
+		fmt.Printf("Immature coinbase balance of '%s': %d\n", address, immature)
+	}
 }
 
 //打印使用帮助文档
 func (cli *CLI) printUsage(){
-	fmt.Println("Usage:")
+	fmt.Println("Usage: [-datadir DIR] [-network " + NetworkNames() + "] [-coinbasematurity N] COMMAND")
+	fmt.Println("DIR defaults to ~/" + defaultDataDirName + "; use -datadir . to keep the chain in the current directory.")
+	fmt.Println("Options can also be set in DIR/" + configFile + " or with " + envPrefix + "* environment variables.")
+	fmt.Println("Commands:")
 	fmt.Println("  printchain")
 	fmt.Println("  getbalance -address ADDRESS")
-	fmt.Println("  createblockchain -address ADDRESS")
-	fmt.Println("  send -from FROM -to TO -amount AMOUNT")
+	fmt.Println("  createblockchain")
+	fmt.Println("  generate -n N -to ADDRESS")
+	fmt.Println("  send -from FROM[,FROM...] -to TO -amount AMOUNT [-change ADDRESS] [-coinselect " + CoinSelectorNames() + "]")
+	fmt.Println("  sendmany -from FROM[,FROM...] -file PAYMENTS.json|PAYMENTS.csv [-change ADDRESS] [-coinselect " + CoinSelectorNames() + "]")
+	fmt.Println("  createrawtransaction -inputs TXID:VOUT[,TXID:VOUT...] -outputs ADDRESS:AMOUNT[,ADDRESS:AMOUNT...]")
+	fmt.Println("  signrawtransaction -hex HEX -address ADDRESS")
+	fmt.Println("  decoderawtransaction -hex HEX")
+	fmt.Println("  sendrawtransaction -hex HEX")
+	fmt.Println("  createpsbt -inputs TXID:VOUT[,TXID:VOUT...] -outputs ADDRESS:AMOUNT[,ADDRESS:AMOUNT...]")
+	fmt.Println("  signpsbt -psbt PSBT -address ADDRESS")
+	fmt.Println("  combinepsbt -psbts PSBT,PSBT[,PSBT...]")
+	fmt.Println("  finalizepsbt -psbt PSBT")
+	fmt.Println("  extractpsbt -psbt PSBT")
 }
 
 //校验命令输入合法性
@@ -63,6 +103,8 @@ func (cli *CLI) printChain(){
 		//对该区块的PoW做一次验证
 		pow := NewProofOfWork(block)
 		fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate()))
+		//校验区块时间戳（中位时间与未来时间）
+		fmt.Printf("Timestamp: %d (valid: %t)\n", block.Timestamp, bc.CheckBlockTimestamp(block) == nil)
 		fmt.Println()
 
 		//当区块链空了便跳出循环
@@ -72,49 +114,274 @@ func (cli *CLI) printChain(){
 	}
 }
 
-func (cli *CLI) send(from, to string, amount int) {
-	bc := NewBlockchain(from)
+func (cli *CLI) send(from []string, to string, amount int, change, coinSelect string) {
+	selector, err := GetCoinSelector(coinSelect)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	bc := NewBlockchain(from[0])
 	defer bc.Db.Close()
 
-	tx := NewUTXOTransaction(from, to, amount, bc)
+	tx, err := NewMultiSourceTransaction(from, []Payment{{to, amount}}, change, selector, bc)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
 	bc.AddBlock([]*Transaction{tx})
 	fmt.Println("Success!")
 }
 
+func (cli *CLI) sendMany(from []string, file, change, coinSelect string) {
+	selector, err := GetCoinSelector(coinSelect)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	payments, err := readPayments(file)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	bc := NewBlockchain(from[0])
+	defer bc.Db.Close()
+
+	tx, err := NewMultiSourceTransaction(from, payments, change, selector, bc)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	bc.AddBlock([]*Transaction{tx})
+	fmt.Printf("Success! Paid %d recipient(s).\n", len(payments))
+}
+
+// parseAddresses 解析以逗号分隔的地址列表
+func parseAddresses(list string) []string {
+	var addresses []string
+	for _, address := range strings.Split(list, ",") {
+		address = strings.TrimSpace(address)
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// readPayments 从 JSON 或 CSV 文件读取收款地址和金额
+// JSON 格式: [{"address": "ADDR", "amount": 1}, ...]
+// CSV 格式: 每行 ADDR,AMOUNT（允许 address,amount 表头）
+func readPayments(file string) ([]Payment, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var payments []Payment
+	trimmed := bytes.TrimSpace(data)
+	if strings.EqualFold(filepath.Ext(file), ".json") || bytes.HasPrefix(trimmed, []byte("[")) {
+		err = json.Unmarshal(trimmed, &payments)
+	} else {
+		payments, err = readPaymentsCSV(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	if len(payments) == 0 {
+		return nil, fmt.Errorf("%s: no payments found", file)
+	}
+	for i, p := range payments {
+		if p.Address == "" || p.Amount <= 0 {
+			return nil, fmt.Errorf("%s: payment %d: address must be set and amount must be positive", file, i+1)
+		}
+	}
+
+	return payments, nil
+}
+
+func readPaymentsCSV(r io.Reader) ([]Payment, error) {
+	var payments []Payment
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		amount, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			// 第一行可以是表头
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid amount '%s'", line, record[1])
+		}
+		payments = append(payments, Payment{strings.TrimSpace(record[0]), amount})
+	}
+
+	return payments, nil
+}
+
 //解析命令行参数并执行命令
 func (cli *CLI) Run(){
 	cli.validateArgs()
+
+	//全局选项，写在命令之前
+	globalFlags := flag.NewFlagSet("global", flag.ExitOnError)
+	globalFlags.Usage = cli.printUsage
+	globalFlags.String("datadir", "~/"+defaultDataDirName, "Root data directory")
+	globalFlags.String("network", MainNetParams.Name, "Network to use: "+NetworkNames())
+	globalFlags.String("coinbasematurity", "", "Confirmations a coinbase output needs before it can be spent (default: the network's)")
+	err := globalFlags.Parse(os.Args[1:])
+	if err != nil {
+		log.Panic(err)
+	}
+	args := globalFlags.Args()
+	if len(args) < 1 {
+		cli.printUsage()
+		os.Exit(1)
+	}
+
+	//只有显式给出的参数才覆盖环境变量和配置文件
+	setFlags := make(map[string]string)
+	globalFlags.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = f.Value.String()
+	})
+	cfg, err := LoadConfig(setFlags)
+	if err == nil {
+		err = cfg.Apply()
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	//提供的可用命令
 	getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
 	sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtransaction", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtransaction", flag.ExitOnError)
+	decodeRawTxCmd := flag.NewFlagSet("decoderawtransaction", flag.ExitOnError)
+	sendRawTxCmd := flag.NewFlagSet("sendrawtransaction", flag.ExitOnError)
+	createPSBTCmd := flag.NewFlagSet("createpsbt", flag.ExitOnError)
+	signPSBTCmd := flag.NewFlagSet("signpsbt", flag.ExitOnError)
+	combinePSBTCmd := flag.NewFlagSet("combinepsbt", flag.ExitOnError)
+	finalizePSBTCmd := flag.NewFlagSet("finalizepsbt", flag.ExitOnError)
+	extractPSBTCmd := flag.NewFlagSet("extractpsbt", flag.ExitOnError)
 
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
-	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
-	sendFrom := sendCmd.String("from", "", "Source wallet address")
+	generateCount := generateCmd.Int("n", 1, "Number of blocks to generate")
+	generateTo := generateCmd.String("to", "", "The address to send the block rewards to")
+	sendFrom := sendCmd.String("from", "", "Source wallet address(es), comma separated")
 	sendTo := sendCmd.String("to", "", "Destination wallet address")
 	sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+	sendChange := sendCmd.String("change", "", "Change address (default: the first source address)")
+	sendCoinSelect := sendCmd.String("coinselect", cfg.CoinSelect, "Coin selection strategy: "+CoinSelectorNames())
+	sendManyFrom := sendManyCmd.String("from", "", "Source wallet address(es), comma separated")
+	sendManyFile := sendManyCmd.String("file", "", "JSON or CSV file of address/amount pairs")
+	sendManyChange := sendManyCmd.String("change", "", "Change address (default: the first source address)")
+	sendManyCoinSelect := sendManyCmd.String("coinselect", cfg.CoinSelect, "Coin selection strategy: "+CoinSelectorNames())
+	createRawTxInputs := createRawTxCmd.String("inputs", "", "Outputs to spend, as TXID:VOUT[,TXID:VOUT...]")
+	createRawTxOutputs := createRawTxCmd.String("outputs", "", "Outputs to create, as ADDRESS:AMOUNT[,ADDRESS:AMOUNT...]")
+	signRawTxHex := signRawTxCmd.String("hex", "", "Hex encoded transaction")
+	signRawTxAddress := signRawTxCmd.String("address", "", "Address to sign the inputs it owns with")
+	decodeRawTxHex := decodeRawTxCmd.String("hex", "", "Hex encoded transaction")
+	sendRawTxHex := sendRawTxCmd.String("hex", "", "Hex encoded signed transaction")
+	createPSBTInputs := createPSBTCmd.String("inputs", "", "Outputs to spend, as TXID:VOUT[,TXID:VOUT...]")
+	createPSBTOutputs := createPSBTCmd.String("outputs", "", "Outputs to create, as ADDRESS:AMOUNT[,ADDRESS:AMOUNT...]")
+	signPSBTData := signPSBTCmd.String("psbt", "", "Hex encoded PSBT")
+	signPSBTAddress := signPSBTCmd.String("address", "", "Address to sign the inputs it owns with")
+	combinePSBTList := combinePSBTCmd.String("psbts", "", "Comma separated hex encoded PSBTs of the same transaction")
+	finalizePSBTData := finalizePSBTCmd.String("psbt", "", "Hex encoded PSBT")
+	extractPSBTData := extractPSBTCmd.String("psbt", "", "Hex encoded finalized PSBT")
 
-	//判断输入的命令(检查第二个参数，第一个为程序名)
-	switch os.Args[1] {
+	//判断输入的命令(全局选项之后的第一个参数)
+	switch args[0] {
 	case "getbalance":
-		err := getBalanceCmd.Parse(os.Args[2:])
+		err := getBalanceCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createblockchain":
-		err := createBlockchainCmd.Parse(os.Args[2:])
+		err := createBlockchainCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "generate":
+		err := generateCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "send":
-		err := sendCmd.Parse(os.Args[2:])
+		err := sendCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendmany":
+		err := sendManyCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "printchain":
-		err := printChainCmd.Parse(os.Args[2:])
+		err := printChainCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createrawtransaction":
+		err := createRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signrawtransaction":
+		err := signRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "decoderawtransaction":
+		err := decodeRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendrawtransaction":
+		err := sendRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createpsbt":
+		err := createPSBTCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signpsbt":
+		err := signPSBTCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "combinepsbt":
+		err := combinePSBTCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "finalizepsbt":
+		err := finalizePSBTCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "extractpsbt":
+		err := extractPSBTCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
@@ -135,11 +402,15 @@ func (cli *CLI) Run(){
 	}
 
 	if createBlockchainCmd.Parsed() {
-		if *createBlockchainAddress == "" {
-			createBlockchainCmd.Usage()
+		cli.createBlockchain()
+	}
+
+	if generateCmd.Parsed() {
+		if *generateTo == "" || *generateCount <= 0 {
+			generateCmd.Usage()
 			os.Exit(1)
 		}
-		cli.createBlockchain(*createBlockchainAddress)
+		cli.generate(*generateCount, *generateTo)
 	}
 
 	if printChainCmd.Parsed(){
@@ -148,10 +419,98 @@ func (cli *CLI) Run(){
 	}
 
 	if sendCmd.Parsed() {
-		if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
+		from := parseAddresses(*sendFrom)
+		if len(from) == 0 || *sendTo == "" || *sendAmount <= 0 {
 			sendCmd.Usage()
 			os.Exit(1)
 		}
-		cli.send(*sendFrom, *sendTo, *sendAmount)
+		if *sendChange == "" {
+			*sendChange = from[0]
+		}
+		cli.send(from, *sendTo, *sendAmount, *sendChange, *sendCoinSelect)
+	}
+
+	if sendManyCmd.Parsed() {
+		from := parseAddresses(*sendManyFrom)
+		if len(from) == 0 || *sendManyFile == "" {
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		if *sendManyChange == "" {
+			*sendManyChange = from[0]
+		}
+		cli.sendMany(from, *sendManyFile, *sendManyChange, *sendManyCoinSelect)
+	}
+
+	if createRawTxCmd.Parsed() {
+		if *createRawTxInputs == "" || *createRawTxOutputs == "" {
+			createRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createRawTransaction(*createRawTxInputs, *createRawTxOutputs)
+	}
+
+	if signRawTxCmd.Parsed() {
+		if *signRawTxHex == "" || *signRawTxAddress == "" {
+			signRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signRawTransaction(*signRawTxHex, *signRawTxAddress)
+	}
+
+	if decodeRawTxCmd.Parsed() {
+		if *decodeRawTxHex == "" {
+			decodeRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.decodeRawTransaction(*decodeRawTxHex)
+	}
+
+	if sendRawTxCmd.Parsed() {
+		if *sendRawTxHex == "" {
+			sendRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.sendRawTransaction(*sendRawTxHex)
+	}
+
+	if createPSBTCmd.Parsed() {
+		if *createPSBTInputs == "" || *createPSBTOutputs == "" {
+			createPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.createPSBT(*createPSBTInputs, *createPSBTOutputs)
+	}
+
+	if signPSBTCmd.Parsed() {
+		if *signPSBTData == "" || *signPSBTAddress == "" {
+			signPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signPSBT(*signPSBTData, *signPSBTAddress)
+	}
+
+	if combinePSBTCmd.Parsed() {
+		if *combinePSBTList == "" {
+			combinePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.combinePSBT(*combinePSBTList)
+	}
+
+	if finalizePSBTCmd.Parsed() {
+		if *finalizePSBTData == "" {
+			finalizePSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.finalizePSBT(*finalizePSBTData)
+	}
+
+	if extractPSBTCmd.Parsed() {
+		if *extractPSBTData == "" {
+			extractPSBTCmd.Usage()
+			os.Exit(1)
+		}
+		cli.extractPSBT(*extractPSBTData)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// createPSBT 在联网（只读）的机器上创建 PSBT，附带每个输入所花费的输出
+func (cli *CLI) createPSBT(inputs, outputs string) {
+	tx, err := parseRawTransaction(inputs, outputs)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	bc := NewBlockchain("")
+	defer bc.Db.Close()
+
+	psbt, err := NewPSBT(tx, bc)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(EncodePSBT(psbt))
+}
+
+// signPSBT 在离线机器上用 address 签名，不需要区块链数据
+func (cli *CLI) signPSBT(encoded, address string) {
+	psbt := decodePSBTOrExit(encoded)
+
+	signed, err := psbt.Sign(address)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(EncodePSBT(psbt))
+	fmt.Printf("Signed %d input(s), complete: %t\n", signed, psbt.Complete())
+}
+
+// combinePSBT 合并多个 PSBT 中分别收集到的签名
+func (cli *CLI) combinePSBT(list string) {
+	var combined *PartiallySignedTransaction
+
+	for _, encoded := range strings.Split(list, ",") {
+		psbt := decodePSBTOrExit(encoded)
+		if combined == nil {
+			combined = psbt
+			continue
+		}
+
+		err := combined.Combine(psbt)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Println(EncodePSBT(combined))
+	fmt.Printf("Complete: %t\n", combined.Complete())
+}
+
+// finalizePSBT 确认所有输入都已签名并标记为已完成，签名在 extractpsbt 时才写入交易
+func (cli *CLI) finalizePSBT(encoded string) {
+	psbt := decodePSBTOrExit(encoded)
+
+	err := psbt.Finalize()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(EncodePSBT(psbt))
+}
+
+// extractPSBT 从已 finalize 的 PSBT 中取出可用 sendrawtransaction 广播的交易
+func (cli *CLI) extractPSBT(encoded string) {
+	psbt := decodePSBTOrExit(encoded)
+
+	tx, err := psbt.Extract()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(EncodeRawTransaction(tx))
+}
+
+func decodePSBTOrExit(encoded string) *PartiallySignedTransaction {
+	psbt, err := DecodePSBT(encoded)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return psbt
+}
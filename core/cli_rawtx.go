@@ -0,0 +1,102 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// createRawTransaction 用明确给出的输入和输出构建未签名交易，输出其十六进制编码
+func (cli *CLI) createRawTransaction(inputs, outputs string) {
+	tx, err := parseRawTransaction(inputs, outputs)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(EncodeRawTransaction(tx))
+}
+
+// parseRawTransaction 解析命令行给出的输入和输出，构建未签名交易
+// inputs 形如 "TXID:VOUT,TXID:VOUT"，outputs 形如 "ADDRESS:AMOUNT,ADDRESS:AMOUNT"
+func parseRawTransaction(inputs, outputs string) (*Transaction, error) {
+	var vin []TXInput
+	var vout []TXOutput
+
+	for _, s := range strings.Split(inputs, ",") {
+		in, err := ParseOutpoint(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		vin = append(vin, in)
+	}
+
+	for _, s := range strings.Split(outputs, ",") {
+		out, err := ParseTXOutput(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		vout = append(vout, out)
+	}
+
+	return NewRawTransaction(vin, vout), nil
+}
+
+// signRawTransaction 用 address 为交易中属于它的输入签名
+func (cli *CLI) signRawTransaction(rawHex, address string) {
+	tx, err := DecodeRawTransaction(rawHex)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	bc := NewBlockchain(address)
+	defer bc.Db.Close()
+
+	signed, complete, err := SignRawTransaction(tx, address, bc)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(EncodeRawTransaction(tx))
+	fmt.Printf("Signed %d input(s), complete: %t\n", signed, complete)
+}
+
+// decodeRawTransaction 以可读的 JSON 输出交易内容
+func (cli *CLI) decodeRawTransaction(rawHex string) {
+	tx, err := DecodeRawTransaction(rawHex)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	out, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Println(string(out))
+}
+
+// sendRawTransaction 校验已签名的交易并将其打包进新区块
+func (cli *CLI) sendRawTransaction(rawHex string) {
+	tx, err := DecodeRawTransaction(rawHex)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	bc := NewBlockchain("")
+	defer bc.Db.Close()
+
+	err = bc.VerifyTransaction(tx)
+	if err != nil {
+		fmt.Printf("ERROR: transaction rejected: %v\n", err)
+		os.Exit(1)
+	}
+
+	bc.AddBlock([]*Transaction{tx})
+	fmt.Printf("Success! txid: %x\n", tx.ID)
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// bnbMaxTries 限制 branch-and-bound 搜索的节点数，避免 UTXO 很多时耗时过长
+const bnbMaxTries = 100000
+
+// UTXO 表示一个未花费的交易输出及其所在位置
+type UTXO struct {
+	TxID          string   // 所在交易的 ID（十六进制）
+	Index         int      // 在交易输出中的索引
+	Output        TXOutput // 输出本身
+	Coinbase      bool     // 是否来自 coinbase 交易
+	Confirmations int      // 确认数，所在区块为最新区块时为 1
+}
+
+// IsMature 判断该输出能否在下一个区块中被花费
+// coinbase 输出需要达到当前网络规定的确认数
+func (u UTXO) IsMature() bool {
+	return !u.Coinbase || u.Confirmations >= activeNet.CoinbaseMaturity
+}
+
+// CoinSelector 从候选 UTXO 中挑选用于支付 amount 的输入
+// 若候选 UTXO 总额不足，返回的集合总额会小于 amount，由调用方处理
+type CoinSelector interface {
+	SelectCoins(utxos []UTXO, amount int) []UTXO
+}
+
+// LargestFirst 优先使用面额最大的输出，输入数量最少
+type LargestFirst struct{}
+
+// SmallestFirst 优先使用面额最小的输出，顺便清理零钱（dust）
+type SmallestFirst struct{}
+
+// BranchAndBound 搜索总额恰好等于 amount 的输出组合，从而不产生找零
+// 找不到精确组合时退回到 Fallback（为空则使用 LargestFirst）
+type BranchAndBound struct {
+	Fallback CoinSelector
+}
+
+// RandomImprove 先随机选取输出直到满足 amount，
+// 再继续随机加入输出，使总额尽量接近 2 倍 amount（不超过 3 倍），
+// 让找零与支付金额相当，避免产生零钱
+type RandomImprove struct{}
+
+// coinSelectors 为命令行可选的选币策略
+var coinSelectors = map[string]CoinSelector{
+	"largest":  LargestFirst{},
+	"smallest": SmallestFirst{},
+	"bnb":      BranchAndBound{},
+	"random":   RandomImprove{},
+}
+
+// DefaultCoinSelector 为未指定策略时使用的选币策略名
+const DefaultCoinSelector = "bnb"
+
+// GetCoinSelector 按名称返回选币策略
+func GetCoinSelector(name string) (CoinSelector, error) {
+	selector, ok := coinSelectors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown coin selection strategy '%s' (available: %s)", name, CoinSelectorNames())
+	}
+	return selector, nil
+}
+
+// CoinSelectorNames 返回所有可用的选币策略名，以 '|' 分隔
+func CoinSelectorNames() string {
+	var names []string
+	for name := range coinSelectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+// sumUTXOs 计算一组 UTXO 的总额
+func sumUTXOs(utxos []UTXO) int {
+	total := 0
+	for _, u := range utxos {
+		total += u.Output.Value
+	}
+	return total
+}
+
+// accumulate 按给定顺序选取输出，直到总额达到 amount
+func accumulate(utxos []UTXO, amount int) []UTXO {
+	var selected []UTXO
+	accumulated := 0
+
+	for _, u := range utxos {
+		if accumulated >= amount {
+			break
+		}
+		selected = append(selected, u)
+		accumulated += u.Output.Value
+	}
+
+	return selected
+}
+
+// sortedUTXOs 返回按面额排序后的副本，不修改传入的切片
+func sortedUTXOs(utxos []UTXO, descending bool) []UTXO {
+	sorted := make([]UTXO, len(utxos))
+	copy(sorted, utxos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if descending {
+			return sorted[i].Output.Value > sorted[j].Output.Value
+		}
+		return sorted[i].Output.Value < sorted[j].Output.Value
+	})
+	return sorted
+}
+
+func (LargestFirst) SelectCoins(utxos []UTXO, amount int) []UTXO {
+	return accumulate(sortedUTXOs(utxos, true), amount)
+}
+
+func (SmallestFirst) SelectCoins(utxos []UTXO, amount int) []UTXO {
+	return accumulate(sortedUTXOs(utxos, false), amount)
+}
+
+func (s BranchAndBound) SelectCoins(utxos []UTXO, amount int) []UTXO {
+	sorted := sortedUTXOs(utxos, true)
+
+	// remaining[i] 为 sorted[i:] 的总额，用于剪枝
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Output.Value
+	}
+
+	var selected []int
+	tries := 0
+
+	// 深度优先：对每个输出先尝试选入，再尝试不选
+	var search func(i, total int) bool
+	search = func(i, total int) bool {
+		tries++
+		if total == amount {
+			return true
+		}
+		if total > amount || total+remaining[i] < amount || tries > bnbMaxTries {
+			return false
+		}
+
+		selected = append(selected, i)
+		if search(i+1, total+sorted[i].Output.Value) {
+			return true
+		}
+		selected = selected[:len(selected)-1]
+
+		return search(i+1, total)
+	}
+
+	if amount > 0 && search(0, 0) {
+		var result []UTXO
+		for _, i := range selected {
+			result = append(result, sorted[i])
+		}
+		return result
+	}
+
+	fallback := s.Fallback
+	if fallback == nil {
+		fallback = LargestFirst{}
+	}
+	return fallback.SelectCoins(utxos, amount)
+}
+
+func (RandomImprove) SelectCoins(utxos []UTXO, amount int) []UTXO {
+	shuffled := make([]UTXO, len(utxos))
+	copy(shuffled, utxos)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	// 随机选取阶段
+	selected := accumulate(shuffled, amount)
+	accumulated := sumUTXOs(selected)
+	if accumulated < amount {
+		return selected
+	}
+
+	// 改进阶段：加入的输出须让总额更接近理想值且不超过上限
+	ideal, limit := 2*amount, 3*amount
+	for _, u := range shuffled[len(selected):] {
+		next := accumulated + u.Output.Value
+		if next > limit || abs(ideal-next) >= abs(ideal-accumulated) {
+			continue
+		}
+		selected = append(selected, u)
+		accumulated = next
+	}
+
+	return selected
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
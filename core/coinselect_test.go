@@ -0,0 +1,93 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// utxosOf 用给定面额构造一组 UTXO
+func utxosOf(values ...int) []UTXO {
+	var utxos []UTXO
+	for i, v := range values {
+		utxos = append(utxos, UTXO{TxID: fmt.Sprintf("%02x", i), Output: TXOutput{v, "a"}})
+	}
+	return utxos
+}
+
+// valuesOf 返回一组 UTXO 的面额，按从大到小排序
+func valuesOf(utxos []UTXO) []int {
+	values := []int{}
+	for _, u := range utxos {
+		values = append(values, u.Output.Value)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(values)))
+	return values
+}
+
+// repeat 返回 n 个 v
+func repeat(v, n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = v
+	}
+	return values
+}
+
+func TestSelectCoins(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector CoinSelector
+		values   []int
+		amount   int
+		want     []int
+	}{
+		{"largest first", LargestFirst{}, []int{1, 5, 3}, 6, []int{5, 3}},
+		{"smallest first", SmallestFirst{}, []int{5, 1, 3}, 3, []int{3, 1}},
+		{"insufficient funds", LargestFirst{}, []int{1, 2}, 10, []int{2, 1}},
+		{"bnb exact match", BranchAndBound{}, []int{5, 3, 2}, 7, []int{5, 2}},
+		{"bnb exact match skips largest", BranchAndBound{}, []int{6, 4, 3}, 7, []int{4, 3}},
+		{"bnb falls back to largest first", BranchAndBound{}, []int{5, 3}, 4, []int{5}},
+		{"bnb custom fallback", BranchAndBound{SmallestFirst{}}, []int{5, 3}, 4, []int{5, 3}},
+		{"bnb zero amount", BranchAndBound{}, []int{5, 3}, 0, []int{}},
+		{"random zero amount", RandomImprove{}, []int{5, 3}, 0, []int{}},
+		// 3 排在最前，含 3 的分支永远凑不出偶数 40，搜索在 bnbMaxTries 后放弃，
+		// 尽管不含 3 的 20 个 2 恰好等于 40，结果仍是 fallback 的选择
+		{"bnb gives up after bnbMaxTries", BranchAndBound{}, append([]int{3}, repeat(2, 40)...), 40, append([]int{3}, repeat(2, 19)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := valuesOf(tt.selector.SelectCoins(utxosOf(tt.values...), tt.amount))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectCoins(%v, %d) = %v, want %v", tt.values, tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomImproveCap(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int
+		amount int
+	}{
+		// 10 与 21 同时选中为 31，超过 3 倍上限，只能选其中一个
+		{"over the cap", []int{10, 21}, 10},
+		{"many small outputs", repeat(3, 30), 10},
+		{"equal outputs", repeat(5, 6), 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				selected := RandomImprove{}.SelectCoins(utxosOf(tt.values...), tt.amount)
+				total := sumUTXOs(selected)
+				if total < tt.amount || total > 3*tt.amount {
+					t.Fatalf("selected %v, total %d is outside [%d, %d]", valuesOf(selected), total, tt.amount, 3*tt.amount)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const configFile = "coin.conf"             //配置文件，位于数据目录下
+const envPrefix = "COIN_"                  //环境变量前缀，如 COIN_NETWORK
+const defaultDataDirName = ".bitcoin_fake" //默认的根数据目录名，位于用户主目录下
+
+// Config 为程序的运行配置
+// 优先级从高到低：命令行参数、环境变量、配置文件、默认值
+type Config struct {
+	DataDir    string // 根数据目录，各网络的数据存放在其下的子目录中
+	Network    string // 使用的网络
+	CoinSelect string // send/sendmany 默认的选币策略
+
+	CoinbaseMaturity int // coinbase 输出需要的确认数，为 0 时使用网络参数中的默认值
+}
+
+// dataDir 为当前使用的根数据目录
+var dataDir = defaultDataDir()
+
+// defaultDataDir 返回默认的根数据目录 ~/.bitcoin_fake，与当前工作目录无关
+// 无法确定用户主目录时返回空字符串
+func defaultDataDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, defaultDataDirName)
+}
+
+// configOptions 为配置文件和环境变量中可以设置的选项
+var configOptions = map[string]func(cfg *Config, value string) error{
+	"network": func(cfg *Config, value string) error {
+		cfg.Network = value
+		return nil
+	},
+	"coinselect": func(cfg *Config, value string) error {
+		cfg.CoinSelect = value
+		return nil
+	},
+	"coinbasematurity": func(cfg *Config, value string) error {
+		maturity, err := strconv.Atoi(value)
+		if err != nil || maturity < 1 {
+			return fmt.Errorf("coinbasematurity must be a whole number of at least 1, got '%s'", value)
+		}
+		cfg.CoinbaseMaturity = maturity
+		return nil
+	},
+}
+
+// LoadConfig 读取配置：flags 为命令行中显式给出的全局参数（不含 '-'）
+//
+// 配置文件为 INI 格式，每行一个 key = value，'#' 或 ';' 开头为注释；
+// [mainnet]、[testnet]、[regtest] 段中的选项只在使用对应网络时生效
+func LoadConfig(flags map[string]string) (*Config, error) {
+	cfg := &Config{
+		DataDir:    defaultDataDir(),
+		Network:    MainNetParams.Name,
+		CoinSelect: DefaultCoinSelector,
+	}
+
+	// 数据目录决定了配置文件的位置，需要最先确定
+	if value, ok := os.LookupEnv(envPrefix + "DATADIR"); ok {
+		cfg.DataDir = value
+	}
+	if value, ok := flags["datadir"]; ok {
+		cfg.DataDir = value
+	}
+	//没有经过 shell 展开的 "~/" 按用户主目录处理
+	if strings.HasPrefix(cfg.DataDir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		cfg.DataDir = filepath.Join(home, cfg.DataDir[2:])
+	}
+	if cfg.DataDir == "" {
+		return nil, fmt.Errorf("cannot determine the home directory, set -datadir or %sDATADIR", envPrefix)
+	}
+	absDir, err := filepath.Abs(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.DataDir = absDir
+
+	sections, err := readConfigFile(filepath.Join(cfg.DataDir, configFile))
+	if err != nil {
+		return nil, err
+	}
+	for name := range sections {
+		if name != "" && findNetwork(name) == nil {
+			return nil, fmt.Errorf("%s: unknown section [%s]", configFile, name)
+		}
+	}
+
+	// 先确定网络，再应用对应网络段中的选项
+	if err = applyOptions(cfg, sections[""]); err != nil {
+		return nil, err
+	}
+	if value, ok := os.LookupEnv(envPrefix + "NETWORK"); ok {
+		cfg.Network = value
+	}
+	if value, ok := flags["network"]; ok {
+		cfg.Network = value
+	}
+	if _, ok := sections[cfg.Network]["network"]; ok {
+		return nil, fmt.Errorf("%s: 'network' cannot be set in section [%s]", configFile, cfg.Network)
+	}
+	if err = applyOptions(cfg, sections[cfg.Network]); err != nil {
+		return nil, err
+	}
+
+	for key, apply := range configOptions {
+		if value, ok := os.LookupEnv(envPrefix + strings.ToUpper(key)); ok {
+			if err = apply(cfg, value); err != nil {
+				return nil, fmt.Errorf("%s%s: %v", envPrefix, strings.ToUpper(key), err)
+			}
+		}
+	}
+	for key, apply := range configOptions {
+		if value, ok := flags[key]; ok {
+			if err = apply(cfg, value); err != nil {
+				return nil, fmt.Errorf("-%s: %v", key, err)
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// Apply 使配置生效：设置数据目录、切换网络，并覆盖网络参数中可配置的部分
+func (cfg *Config) Apply() error {
+	_, err := GetCoinSelector(cfg.CoinSelect)
+	if err != nil {
+		return err
+	}
+
+	dataDir = cfg.DataDir
+	err = SelectNetwork(cfg.Network)
+	if err != nil {
+		return err
+	}
+
+	if cfg.CoinbaseMaturity > 0 {
+		activeNet.CoinbaseMaturity = cfg.CoinbaseMaturity
+	}
+	return nil
+}
+
+func applyOptions(cfg *Config, options map[string]string) error {
+	for key, value := range options {
+		apply, ok := configOptions[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown option '%s'", configFile, key)
+		}
+		if err := apply(cfg, value); err != nil {
+			return fmt.Errorf("%s: %v", configFile, err)
+		}
+	}
+	return nil
+}
+
+// readConfigFile 读取配置文件，按段返回其中的选项，无段名的选项在 "" 中
+// 文件不存在时返回空配置
+func readConfigFile(path string) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{"": {}}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return sections, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if sections[section] == nil {
+				sections[section] = map[string]string{}
+			}
+			continue
+		}
+
+		sep := strings.Index(line, "=")
+		if sep < 0 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNo)
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:sep]))
+		value := strings.Trim(strings.TrimSpace(line[sep+1:]), `"`)
+		sections[section][key] = value
+	}
+
+	return sections, scanner.Err()
+}
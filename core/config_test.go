@@ -0,0 +1,201 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearConfigEnv 清除所有 COIN_* 环境变量，测试结束后恢复
+func clearConfigEnv(t *testing.T) {
+	keys := []string{"DATADIR"}
+	for key := range configOptions {
+		keys = append(keys, strings.ToUpper(key))
+	}
+	for _, key := range keys {
+		t.Setenv(envPrefix+key, "")
+		os.Unsetenv(envPrefix + key)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		flags   map[string]string
+		want    Config
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			want: Config{Network: "mainnet", CoinSelect: DefaultCoinSelector},
+		},
+		{
+			name: "file over default",
+			file: "coinselect = smallest\ncoinbasematurity = 5\n",
+			want: Config{Network: "mainnet", CoinSelect: "smallest", CoinbaseMaturity: 5},
+		},
+		{
+			name: "env over file",
+			file: "coinselect = smallest\ncoinbasematurity = 5\n",
+			env:  map[string]string{"COIN_COINSELECT": "largest", "COIN_COINBASEMATURITY": "6"},
+			want: Config{Network: "mainnet", CoinSelect: "largest", CoinbaseMaturity: 6},
+		},
+		{
+			name:  "flag over env",
+			file:  "coinbasematurity = 5\n",
+			env:   map[string]string{"COIN_COINBASEMATURITY": "6"},
+			flags: map[string]string{"coinbasematurity": "7"},
+			want:  Config{Network: "mainnet", CoinSelect: DefaultCoinSelector, CoinbaseMaturity: 7},
+		},
+		{
+			name: "comments and quotes",
+			file: "# comment\n; comment\n\nCoinSelect = \"random\"\n",
+			want: Config{Network: "mainnet", CoinSelect: "random"},
+		},
+		{
+			name: "section of the selected network",
+			file: "network = regtest\ncoinselect = smallest\n[regtest]\ncoinselect = random\n[testnet]\ncoinselect = largest\n",
+			want: Config{Network: "regtest", CoinSelect: "random"},
+		},
+		{
+			name:  "section follows the network flag",
+			file:  "network = regtest\n[regtest]\ncoinselect = random\n[testnet]\ncoinselect = largest\n",
+			flags: map[string]string{"network": "testnet"},
+			want:  Config{Network: "testnet", CoinSelect: "largest"},
+		},
+		{
+			name: "section follows the network env",
+			file: "[regtest]\ncoinbasematurity = 1\n",
+			env:  map[string]string{"COIN_NETWORK": "regtest"},
+			want: Config{Network: "regtest", CoinSelect: DefaultCoinSelector, CoinbaseMaturity: 1},
+		},
+		{
+			name: "env over section",
+			file: "[regtest]\ncoinselect = random\n",
+			env:  map[string]string{"COIN_NETWORK": "regtest", "COIN_COINSELECT": "largest"},
+			want: Config{Network: "regtest", CoinSelect: "largest"},
+		},
+		{
+			name: "other sections are ignored",
+			file: "[regtest]\ncoinselect = random\n",
+			want: Config{Network: "mainnet", CoinSelect: DefaultCoinSelector},
+		},
+		{
+			name:    "unknown key",
+			file:    "colour = blue\n",
+			wantErr: "unknown option 'colour'",
+		},
+		{
+			name:    "unknown key in a section",
+			file:    "[regtest]\ncolour = blue\n",
+			flags:   map[string]string{"network": "regtest"},
+			wantErr: "unknown option 'colour'",
+		},
+		{
+			name:    "unknown section",
+			file:    "[simnet]\ncoinselect = random\n",
+			wantErr: "unknown section [simnet]",
+		},
+		{
+			name:    "network inside a section",
+			file:    "[regtest]\nnetwork = testnet\n",
+			flags:   map[string]string{"network": "regtest"},
+			wantErr: "'network' cannot be set in section [regtest]",
+		},
+		{
+			name:    "line without a value",
+			file:    "coinselect\n",
+			wantErr: "expected key = value",
+		},
+		{
+			name:    "invalid maturity in the file",
+			file:    "coinbasematurity = 0\n",
+			wantErr: "coinbasematurity must be",
+		},
+		{
+			name:    "invalid maturity flag",
+			flags:   map[string]string{"coinbasematurity": "soon"},
+			wantErr: "-coinbasematurity: coinbasematurity must be",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			dir := t.TempDir()
+			if tt.file != "" {
+				err := os.WriteFile(filepath.Join(dir, configFile), []byte(tt.file), 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			flags := map[string]string{"datadir": dir}
+			for key, value := range tt.flags {
+				flags[key] = value
+			}
+
+			cfg, err := LoadConfig(flags)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+
+			tt.want.DataDir = dir
+			if *cfg != tt.want {
+				t.Errorf("LoadConfig() = %+v, want %+v", *cfg, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDataDir(t *testing.T) {
+	home := t.TempDir()
+	other := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		env   string
+		flags map[string]string
+		want  string
+	}{
+		{"default under home", "", nil, filepath.Join(home, defaultDataDirName)},
+		{"env", other, nil, other},
+		{"flag over env", other, map[string]string{"datadir": home}, home},
+		{"tilde expanded", "", map[string]string{"datadir": "~/chain"}, filepath.Join(home, "chain")},
+		{"dot is the current directory", "", map[string]string{"datadir": "."}, cwd},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			t.Setenv("HOME", home)
+			if tt.env != "" {
+				t.Setenv(envPrefix+"DATADIR", tt.env)
+			}
+
+			cfg, err := LoadConfig(tt.flags)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.DataDir != tt.want {
+				t.Errorf("DataDir = %s, want %s", cfg.DataDir, tt.want)
+			}
+		})
+	}
+}
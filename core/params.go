@@ -0,0 +1,145 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ChainParams 汇总了一条区块链（网络）的参数
+type ChainParams struct {
+	Name        string  // 网络名称
+	Net         [4]byte // 网络魔数，用于区分不同网络的消息和数据
+	DefaultPort string  // 默认的 P2P 端口
+	DataDir     string  // 数据目录（相对于 -datadir 指定的根数据目录），各网络互不干扰
+
+	AddressVersion byte // 地址版本字节
+
+	// 创世区块完全由以下参数决定，同一网络的所有节点得到同一个创世区块
+	GenesisCoinbaseData string // 创世区块 coinbase 交易携带的数据
+	GenesisAddress      string // 创世区块奖励的接收地址
+	GenesisTimestamp    int64  // 创世区块的时间戳
+	GenesisNonce        int    // 创世区块的 PoW 随机数
+	GenesisHash         string // 创世区块的哈希（十六进制），用于校验以上参数，打开已有区块链时也以它为检查点
+
+	Subsidy                int // 初始的区块奖励
+	SubsidyHalvingInterval int // 每隔多少个区块奖励减半
+	CoinbaseMaturity       int // coinbase 输出需要多少个确认才能被花费
+
+	TargetBits int // PoW 计算的目标位数，该数越大难度越高
+}
+
+// MainNetParams 主网参数，沿用最初写死的常量；
+// 数据直接存放在根数据目录下。固定创世区块之前创建的 blockchain.db 创世区块不同，无法再打开，需要重新创建
+var MainNetParams = ChainParams{
+	Name:        "mainnet",
+	Net:         [4]byte{0xf9, 0xbe, 0xb4, 0xd9},
+	DefaultPort: "8333",
+	DataDir:     ".",
+
+	AddressVersion: 0x00,
+
+	GenesisCoinbaseData: "Blank Data",
+	GenesisAddress:      "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa",
+	GenesisTimestamp:    1231006505,
+	GenesisNonce:        1226,
+	GenesisHash:         "001baa41d0fcb64ecceb47422ec25461b2f62fbb110f184786a745fc482b5b2b",
+
+	Subsidy:                10,
+	SubsidyHalvingInterval: 210000,
+	CoinbaseMaturity:       100,
+
+	TargetBits: 8,
+}
+
+// TestNetParams 测试网参数
+var TestNetParams = ChainParams{
+	Name:        "testnet",
+	Net:         [4]byte{0x0b, 0x11, 0x09, 0x07},
+	DefaultPort: "18333",
+	DataDir:     "testnet",
+
+	AddressVersion: 0x6f,
+
+	GenesisCoinbaseData: "Blank Data (testnet)",
+	GenesisAddress:      "mpXwg4jMtRhuSpVq4xS3HFHmCmWp9NyGKt",
+	GenesisTimestamp:    1296688602,
+	GenesisNonce:        120,
+	GenesisHash:         "005b56a3ab7ad626147521948f0e69843aad2f41912bb2db68cedbef52f52fa0",
+
+	Subsidy:                10,
+	SubsidyHalvingInterval: 210000,
+	CoinbaseMaturity:       100,
+
+	TargetBits: 8,
+}
+
+// RegTestParams 回归测试网参数，难度最低，适合本地测试
+var RegTestParams = ChainParams{
+	Name:        "regtest",
+	Net:         [4]byte{0xfa, 0xbf, 0xb5, 0xda},
+	DefaultPort: "18444",
+	DataDir:     "regtest",
+
+	AddressVersion: 0x6f,
+
+	GenesisCoinbaseData: "Blank Data (regtest)",
+	GenesisAddress:      "mpXwg4jMtRhuSpVq4xS3HFHmCmWp9NyGKt",
+	GenesisTimestamp:    1296688602,
+	GenesisNonce:        0,
+	GenesisHash:         "fbf9cbe398a3cf0fe1432bfd77e7c58e261dc96990af7dfcbb2a3355bcc724bf",
+
+	Subsidy:                10,
+	SubsidyHalvingInterval: 150,
+	CoinbaseMaturity:       100,
+
+	TargetBits: 0,
+}
+
+// networks 为可以通过 -network 选择的网络
+var networks = []*ChainParams{&MainNetParams, &TestNetParams, &RegTestParams}
+
+// activeNet 为当前使用的网络参数，默认主网
+var activeNet = &MainNetParams
+
+// SelectNetwork 按名称切换当前使用的网络
+func SelectNetwork(name string) error {
+	params := findNetwork(name)
+	if params == nil {
+		return fmt.Errorf("unknown network '%s' (available: %s)", name, NetworkNames())
+	}
+	activeNet = params
+	return nil
+}
+
+func findNetwork(name string) *ChainParams {
+	for _, params := range networks {
+		if params.Name == name {
+			return params
+		}
+	}
+	return nil
+}
+
+// NetworkNames 返回所有可用的网络名，以 '|' 分隔
+func NetworkNames() string {
+	var names []string
+	for _, params := range networks {
+		names = append(names, params.Name)
+	}
+	return strings.Join(names, "|")
+}
+
+// BlockSubsidy 返回高度为 height 的区块的奖励
+func (p *ChainParams) BlockSubsidy(height int) int {
+	halvings := height / p.SubsidyHalvingInterval
+	if halvings >= 63 {
+		return 0
+	}
+	return p.Subsidy >> uint(halvings)
+}
+
+// dbPath 返回当前网络的区块链数据文件路径
+func dbPath() string {
+	return filepath.Join(dataDir, activeNet.DataDir, dbFile)
+}
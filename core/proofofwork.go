@@ -12,8 +12,6 @@ var (
 	maxNonce = math.MaxInt64	//最大的随机数范围
 )
 
-const targetBits = 8		//计算的目标位数，该数越大难度越高
-
 //工作量证明的结构
 type ProofOfWork struct {
 	block  *Block		//区块数据
@@ -23,7 +21,8 @@ type ProofOfWork struct {
 //创建一个目标target并传递
 func NewProofOfWork(b *Block) *ProofOfWork {
 	target := big.NewInt(1)
-	target.Lsh(target, uint(256 - targetBits))
+	//计算的目标位数由当前网络决定，该数越大难度越高
+	target.Lsh(target, uint(256 - activeNet.TargetBits))
 	//target左移位后与区块数据合并为一个结构体并返回
 	pow := &ProofOfWork{b, target}
 	return pow
@@ -36,7 +35,7 @@ func (pow *ProofOfWork) prepareData(nonce int) []byte {
 			pow.block.PrevBlockHash,
 			pow.block.HashTransactions(),
 			IntToHex(pow.block.Timestamp),
-			IntToHex(int64(targetBits)),	//计算难度
+			IntToHex(int64(activeNet.TargetBits)),	//计算难度
 			IntToHex(int64(nonce)),		//自增随机数
 		},
 		[]byte{},
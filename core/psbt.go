@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// PartiallySignedTransaction 是用于离线签名的交易容器（类似 PSBT）
+// Tx 为未签名交易，始终保持未签名，其 ID 即为 PSBT 的标识；
+// Inputs 与 Tx.Vin 一一对应，携带被花费的输出和已收集的签名，
+// 离线签名时不需要访问区块链
+type PartiallySignedTransaction struct {
+	Tx        Transaction
+	Inputs    []PSBTInput
+	Finalized bool
+}
+
+// PSBTInput 记录一个输入所花费的输出，以及为它收集到的签名（解锁数据）
+type PSBTInput struct {
+	PrevOut   TXOutput
+	ScriptSig string
+}
+
+// NewPSBT 为交易创建一个 PSBT，从区块链中查找每个输入所花费的输出
+func NewPSBT(tx *Transaction, bc *Blockchain) (*PartiallySignedTransaction, error) {
+	unsigned := NewRawTransaction(tx.Vin, tx.Vout)
+	psbt := &PartiallySignedTransaction{Tx: *unsigned}
+
+	for i, in := range unsigned.Vin {
+		prevTx, err := bc.FindTransaction(in.Txid)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %v", i, err)
+		}
+		if in.Vout < 0 || in.Vout >= len(prevTx.Vout) {
+			return nil, fmt.Errorf("input %d: output %x:%d does not exist", i, in.Txid, in.Vout)
+		}
+		psbt.Inputs = append(psbt.Inputs, PSBTInput{prevTx.Vout[in.Vout], ""})
+	}
+
+	return psbt, nil
+}
+
+// Sign 用 address 为所有花费 address 输出的输入签名，返回本次签名的输入数量
+func (p *PartiallySignedTransaction) Sign(address string) (int, error) {
+	if p.Finalized {
+		return 0, fmt.Errorf("PSBT is already finalized")
+	}
+
+	signed := 0
+	for i := range p.Inputs {
+		if p.Inputs[i].PrevOut.CanBeUnlockedWith(address) && p.Inputs[i].ScriptSig == "" {
+			p.Inputs[i].ScriptSig = address
+			signed++
+		}
+	}
+
+	return signed, nil
+}
+
+// Combine 把 other 中收集到的签名合并进来，两者必须包装同一笔未签名交易
+func (p *PartiallySignedTransaction) Combine(other *PartiallySignedTransaction) error {
+	if !bytes.Equal(p.Tx.ID, other.Tx.ID) || len(p.Inputs) != len(other.Inputs) {
+		return fmt.Errorf("PSBTs are for different transactions")
+	}
+
+	for i, in := range other.Inputs {
+		if in.ScriptSig == "" {
+			continue
+		}
+		if p.Inputs[i].ScriptSig != "" && p.Inputs[i].ScriptSig != in.ScriptSig {
+			return fmt.Errorf("input %d has conflicting signatures", i)
+		}
+		p.Inputs[i].ScriptSig = in.ScriptSig
+	}
+	// 合并后需要重新 finalize
+	p.Finalized = false
+
+	return nil
+}
+
+// Complete 判断是否每个输入都有能解锁其输出的签名
+func (p *PartiallySignedTransaction) Complete() bool {
+	for _, in := range p.Inputs {
+		if !in.PrevOut.CanBeUnlockedWith(in.ScriptSig) {
+			return false
+		}
+	}
+	return true
+}
+
+// Finalize 校验每个输入都已有能解锁其输出的签名
+func (p *PartiallySignedTransaction) Finalize() error {
+	for i, in := range p.Inputs {
+		if !in.PrevOut.CanBeUnlockedWith(in.ScriptSig) {
+			return fmt.Errorf("input %d is not signed by the owner of its output", i)
+		}
+	}
+	p.Finalized = true
+
+	return nil
+}
+
+// Extract 从已完成的 PSBT 中取出可以广播的交易，签名在这里才写入交易的输入
+func (p *PartiallySignedTransaction) Extract() (*Transaction, error) {
+	if !p.Finalized {
+		return nil, fmt.Errorf("PSBT is not finalized")
+	}
+
+	vin := make([]TXInput, len(p.Tx.Vin))
+	for i, in := range p.Tx.Vin {
+		vin[i] = TXInput{in.Txid, in.Vout, p.Inputs[i].ScriptSig}
+	}
+
+	tx := Transaction{nil, vin, p.Tx.Vout}
+	tx.SetID()
+
+	return &tx, nil
+}
+
+// EncodePSBT 将 PSBT 编码为十六进制字符串
+func EncodePSBT(p *PartiallySignedTransaction) string {
+	var encoded bytes.Buffer
+
+	enc := gob.NewEncoder(&encoded)
+	err := enc.Encode(p)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return hex.EncodeToString(encoded.Bytes())
+}
+
+// DecodePSBT 从十六进制字符串解析 PSBT
+func DecodePSBT(s string) (*PartiallySignedTransaction, error) {
+	var p PartiallySignedTransaction
+
+	data, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PSBT hex: %v", err)
+	}
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err = decoder.Decode(&p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PSBT data: %v", err)
+	}
+	if len(p.Inputs) != len(p.Tx.Vin) {
+		return nil, fmt.Errorf("invalid PSBT: %d inputs but %d input records", len(p.Tx.Vin), len(p.Inputs))
+	}
+	for i, in := range p.Tx.Vin {
+		if in.ScriptSig != "" {
+			return nil, fmt.Errorf("invalid PSBT: input %d of the unsigned transaction is signed", i)
+		}
+	}
+	// 防止交易内容被改动而 ID 未变
+	if !bytes.Equal(p.Tx.ID, p.Tx.Hash()) {
+		return nil, fmt.Errorf("invalid PSBT: transaction ID does not match its contents")
+	}
+
+	return &p, nil
+}
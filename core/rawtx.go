@@ -0,0 +1,169 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Serialize 编码交易数据为字节数组
+func (tx Transaction) Serialize() []byte {
+	var encoded bytes.Buffer
+
+	enc := gob.NewEncoder(&encoded)
+	err := enc.Encode(tx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return encoded.Bytes()
+}
+
+// DeserializeTransaction 反编码字节数组到交易数据
+func DeserializeTransaction(data []byte) (*Transaction, error) {
+	var tx Transaction
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&tx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction data: %v", err)
+	}
+
+	return &tx, nil
+}
+
+// DecodeRawTransaction 从十六进制字符串解析交易
+func DecodeRawTransaction(rawHex string) (*Transaction, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(rawHex))
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction hex: %v", err)
+	}
+
+	return DeserializeTransaction(data)
+}
+
+// EncodeRawTransaction 将交易编码为十六进制字符串
+func EncodeRawTransaction(tx *Transaction) string {
+	return hex.EncodeToString(tx.Serialize())
+}
+
+// NewRawTransaction 用明确给出的输入和输出构建一笔未签名的交易
+// 输入的 ScriptSig 留空，需要之后用 SignRawTransaction 签名；传入的 inputs 不会被修改
+func NewRawTransaction(inputs []TXInput, outputs []TXOutput) *Transaction {
+	vin := make([]TXInput, len(inputs))
+	for i, in := range inputs {
+		vin[i] = TXInput{in.Txid, in.Vout, ""}
+	}
+
+	tx := Transaction{nil, vin, outputs}
+	tx.SetID()
+
+	return &tx
+}
+
+// SignRawTransaction 用 address 为交易中所有花费 address 输出的输入签名
+// 返回本次签名的输入数量，以及交易是否所有输入都已签名
+func SignRawTransaction(tx *Transaction, address string, bc *Blockchain) (int, bool, error) {
+	signed := 0
+	complete := true
+
+	for i, in := range tx.Vin {
+		prevTx, err := bc.FindTransaction(in.Txid)
+		if err != nil {
+			return 0, false, err
+		}
+		if in.Vout < 0 || in.Vout >= len(prevTx.Vout) {
+			return 0, false, fmt.Errorf("input %d: output %x:%d does not exist", i, in.Txid, in.Vout)
+		}
+
+		if prevTx.Vout[in.Vout].CanBeUnlockedWith(address) {
+			tx.Vin[i].ScriptSig = address
+			signed++
+		} else if !prevTx.Vout[in.Vout].CanBeUnlockedWith(in.ScriptSig) {
+			complete = false
+		}
+	}
+
+	// 签名改变了交易内容，需要重新计算 ID
+	tx.SetID()
+
+	return signed, complete, nil
+}
+
+// ParseOutpoint 解析 "TXID:VOUT" 形式的交易输出引用
+func ParseOutpoint(s string) (TXInput, error) {
+	sep := strings.LastIndex(s, ":")
+	if sep < 0 {
+		return TXInput{}, fmt.Errorf("invalid input '%s', expected TXID:VOUT", s)
+	}
+
+	txID, err := hex.DecodeString(s[:sep])
+	if err != nil || len(txID) == 0 {
+		return TXInput{}, fmt.Errorf("invalid txid in input '%s'", s)
+	}
+	vout, err := strconv.Atoi(s[sep+1:])
+	if err != nil || vout < 0 {
+		return TXInput{}, fmt.Errorf("invalid output index in input '%s'", s)
+	}
+
+	return TXInput{txID, vout, ""}, nil
+}
+
+// ParseTXOutput 解析 "ADDRESS:AMOUNT" 形式的交易输出
+func ParseTXOutput(s string) (TXOutput, error) {
+	sep := strings.LastIndex(s, ":")
+	if sep <= 0 {
+		return TXOutput{}, fmt.Errorf("invalid output '%s', expected ADDRESS:AMOUNT", s)
+	}
+
+	value, err := strconv.Atoi(s[sep+1:])
+	if err != nil || value <= 0 {
+		return TXOutput{}, fmt.Errorf("invalid amount in output '%s'", s)
+	}
+
+	return TXOutput{value, s[:sep]}, nil
+}
+
+// txInputJSON、txOutputJSON 和 transactionJSON 是交易的可读 JSON 形式
+type txInputJSON struct {
+	Txid      string `json:"txid"`
+	Vout      int    `json:"vout"`
+	ScriptSig string `json:"scriptSig"`
+}
+
+type txOutputJSON struct {
+	N            int    `json:"n"`
+	Value        int    `json:"value"`
+	ScriptPubKey string `json:"scriptPubKey"`
+}
+
+type transactionJSON struct {
+	Txid     string         `json:"txid"`
+	Coinbase bool           `json:"coinbase"`
+	Vin      []txInputJSON  `json:"vin"`
+	Vout     []txOutputJSON `json:"vout"`
+}
+
+// MarshalJSON 以十六进制 ID 的可读形式输出交易
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	view := transactionJSON{
+		Txid:     hex.EncodeToString(tx.ID),
+		Coinbase: tx.IsCoinbase(),
+		Vin:      []txInputJSON{},
+		Vout:     []txOutputJSON{},
+	}
+
+	for _, in := range tx.Vin {
+		view.Vin = append(view.Vin, txInputJSON{hex.EncodeToString(in.Txid), in.Vout, in.ScriptSig})
+	}
+	for n, out := range tx.Vout {
+		view.Vout = append(view.Vout, txOutputJSON{n, out.Value, out.ScriptPubKey})
+	}
+
+	return json.Marshal(view)
+}
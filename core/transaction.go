@@ -1,16 +1,15 @@
 package core
 
 import (
-	"bytes"
 	"crypto/sha256"
-	"encoding/gob"
 	"encoding/hex"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-const subsidy = 10
-
 // Transaction 由交易 ID，输入和输出构成
 type Transaction struct {
 	ID   []byte
@@ -42,17 +41,17 @@ func (tx Transaction) IsCoinbase() bool {
 	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
-func (tx *Transaction) SetID() {
-	var encoded bytes.Buffer
-	var hash [32]byte
+// Hash 计算交易内容的哈希，ID 字段本身不参与计算
+func (tx Transaction) Hash() []byte {
+	tx.ID = nil
+	hash := sha256.Sum256(tx.Serialize())
 
-	enc := gob.NewEncoder(&encoded)
-	err := enc.Encode(tx)
-	if err != nil {
-		log.Panic(err)
-	}
-	hash = sha256.Sum256(encoded.Bytes())
-	tx.ID = hash[:]
+	return hash[:]
+}
+
+// SetID 将交易内容的哈希设置为交易 ID
+func (tx *Transaction) SetID() {
+	tx.ID = tx.Hash()
 }
 
 // 这里的 unlockingData 可以理解为地址
@@ -64,53 +63,119 @@ func (out *TXOutput) CanBeUnlockedWith(unlockingData string) bool {
 	return out.ScriptPubKey == unlockingData
 }
 
+// CoinbaseHeight 返回 coinbase 交易承诺的区块高度
+// coinbase 输入的 ScriptSig 以区块高度开头，后面用空格隔开任意数据（可用作 extra nonce）
+func (tx Transaction) CoinbaseHeight() (int, error) {
+	if !tx.IsCoinbase() {
+		return 0, fmt.Errorf("transaction %x is not a coinbase", tx.ID)
+	}
+
+	field := strings.SplitN(tx.Vin[0].ScriptSig, " ", 2)[0]
+	height, err := strconv.Atoi(field)
+	if err != nil || height < 0 {
+		return 0, fmt.Errorf("coinbase %x does not commit to a block height", tx.ID)
+	}
+
+	return height, nil
+}
+
 // NewCoinbaseTX 构建 coinbase 交易，该没有输入，只有一个输出
-func NewCoinbaseTX(to, data string) *Transaction {
+// 奖励金额由当前网络的奖励规则和区块高度 height 决定；
+// 输入中写入 height，使不同区块中奖励给同一地址的 coinbase 交易 ID 不同，
+// data 跟在高度之后，可以携带 extra nonce 等任意数据
+func NewCoinbaseTX(to, data string, height int) *Transaction {
 	if data == "" {
 		data = fmt.Sprintf("Reward to '%s'", to)
 	}
 
-	txin := TXInput{[]byte{}, -1, data}
-	txout := TXOutput{subsidy, to}
+	txin := TXInput{[]byte{}, -1, fmt.Sprintf("%d %s", height, data)}
+	txout := TXOutput{activeNet.BlockSubsidy(height), to}
 	tx := Transaction{nil, []TXInput{txin}, []TXOutput{txout}}
 	tx.SetID()
 
 	return &tx
 }
 
-// NewUTXOTransaction 创建一笔新的交易
-func  NewUTXOTransaction(from, to string, amount int, bc *Blockchain) *Transaction {
+// Payment 表示一笔支付：向 Address 支付 Amount
+type Payment struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+// NewMultiSourceTransaction 从多个地址的 UTXO 中选取输入，向多个地址付款
+// 每个输入由它所花费输出的地址解锁，找零发往 change
+// 可花费的余额不足时返回错误，并说明是否有尚未成熟的 coinbase 奖励
+func NewMultiSourceTransaction(from []string, payments []Payment, change string, selector CoinSelector, bc *Blockchain) (*Transaction, error) {
 	var inputs []TXInput
 	var outputs []TXOutput
 
+	amount := 0
+	for _, p := range payments {
+		amount += p.Amount
+	}
+
 	// 找到足够的未花费输出
-	acc, validOutputs := bc.FindSpendableOutputs(from, amount)
+	acc, validOutputs := bc.FindSpendableOutputs(from, amount, selector)
 
 	if acc < amount {
-		log.Panic("ERROR: Not enough funds")
+		return nil, insufficientFundsError(from, acc, amount, bc)
 	}
 
-	for txid, outs := range validOutputs {
-		txID, err := hex.DecodeString(txid)
+	// 在生成交易前列出选中的输入
+	fmt.Printf("Selected %d input(s), total %d:\n", len(validOutputs), acc)
+	for _, out := range validOutputs {
+		fmt.Printf("  %s:%d  %d  (%s)\n", out.TxID, out.Index, out.Output.Value, out.Output.ScriptPubKey)
+	}
+
+	for _, out := range validOutputs {
+		txID, err := hex.DecodeString(out.TxID)
 		if err != nil {
 			log.Panic(err)
 		}
 
-		for _, out := range outs {
-			input := TXInput{txID, out, from}
-			inputs = append(inputs, input)
-		}
+		input := TXInput{txID, out.Index, out.Output.ScriptPubKey}
+		inputs = append(inputs, input)
 	}
 
-	outputs = append(outputs, TXOutput{amount, to})
+	for _, p := range payments {
+		outputs = append(outputs, TXOutput{p.Amount, p.Address})
+	}
 
 	// 如果 UTXO 总数超过所需，则产生找零
 	if acc > amount {
-		outputs = append(outputs, TXOutput{acc - amount, from})
+		outputs = append(outputs, TXOutput{acc - amount, change})
 	}
 
 	tx := Transaction{nil, inputs, outputs}
 	tx.SetID()
 
-	return &tx
-}
\ No newline at end of file
+	return &tx, nil
+}
+
+// insufficientFundsError 说明可花费余额为何不足 amount：
+// 如果尚未成熟的 coinbase 奖励能补足差额，给出还需要多少个确认
+func insufficientFundsError(from []string, spendable, amount int, bc *Blockchain) error {
+	var immature []UTXO
+	for _, u := range bc.findUnspentOutputsOf(from) {
+		if !u.IsMature() {
+			immature = append(immature, u)
+		}
+	}
+	if len(immature) == 0 {
+		return fmt.Errorf("not enough funds: %d spendable, %d needed", spendable, amount)
+	}
+
+	// 按成熟的先后顺序累加，找到足以补足差额时还需要的确认数
+	sort.Slice(immature, func(i, j int) bool { return immature[i].Confirmations > immature[j].Confirmations })
+	total := spendable
+	for _, u := range immature {
+		total += u.Output.Value
+		if total >= amount {
+			remaining := activeNet.CoinbaseMaturity - u.Confirmations
+			return fmt.Errorf("not enough mature funds: %d spendable, %d needed; %d more is immature coinbase reward, enough matures after %d more confirmation(s)",
+				spendable, amount, sumUTXOs(immature), remaining)
+		}
+	}
+
+	return fmt.Errorf("not enough funds: %d spendable and %d immature coinbase reward, %d needed", spendable, sumUTXOs(immature), amount)
+}
@@ -20,7 +20,7 @@ func IntToHex(num int64) []byte {
 
 //判断数据库文件是否存在
 func dbExists() bool {
-	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
+	if _, err := os.Stat(dbPath()); os.IsNotExist(err) {
 		return false
 	}
 